@@ -0,0 +1,443 @@
+// Package client implements the logentries REST api calls used by the gle
+// cli and the gle server mode.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/rfunix/gle/httpclient"
+)
+
+const dateLayout = "2006-01-02 15:04:05"
+const sleepTime = 1000
+
+const logsURL = "https://rest.logentries.com/management/logs"
+const queryURL = "https://rest.logentries.com/query/logs/"
+
+func convertDateToUnixTimestamp(d string) (int64, error) {
+	t, error := time.Parse(dateLayout, d)
+	if error != nil {
+		return 0, error
+	}
+
+	ut := t.UnixNano() / int64(time.Millisecond)
+
+	return ut, nil
+}
+
+// Command holds everything needed to query logentries and talk to its api.
+type Command struct {
+	LogName   string
+	APIKey    string
+	StartDate string
+	EndDate   string
+	Query     string
+	Client    *httpclient.Client
+
+	// MaxPollDuration bounds how long HandleLogs is allowed to keep following
+	// poll links for, independently of the ctx passed to Run. Zero means no
+	// additional bound is applied beyond ctx itself.
+	MaxPollDuration time.Duration
+
+	// OnRequest, when set, is called once for every response received by
+	// FetchLogs, PostQuery and each poll made by HandleLogsEvents, so callers
+	// (such as the stats package) can record query statistics without
+	// Command needing to know anything about them. Requests that fail
+	// without a response, and direct calls to GetLogMessages, are not
+	// reported.
+	OnRequest func(RequestStat)
+}
+
+// RequestStat describes a single HTTP round trip made on behalf of a Command.
+type RequestStat struct {
+	Method   string
+	Status   int
+	Duration time.Duration
+	Bytes    int64
+}
+
+func (cmd *Command) observe(method string, status int, d time.Duration, bytes int64) {
+	if cmd.OnRequest == nil {
+		return
+	}
+	cmd.OnRequest(RequestStat{Method: method, Status: status, Duration: d, Bytes: bytes})
+}
+
+// NewCommand creates a Command ready to fetch logs and run queries.
+func NewCommand(logName, apiKey, startDate, endDate, query string) *Command {
+	return &Command{
+		LogName:   logName,
+		APIKey:    apiKey,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Query:     query,
+		Client:    httpclient.New(),
+	}
+}
+
+// LogsResponse is the payload returned by the management/logs endpoint.
+type LogsResponse struct {
+	Logs []LogResponse `json:"logs"`
+}
+
+// GetLogByName returns the log with the given name or an error if none is found.
+func (lr *LogsResponse) GetLogByName(n string) (LogResponse, error) {
+	for _, l := range lr.Logs {
+		if l.Name == n {
+			return l, nil
+		}
+	}
+	return LogResponse{}, fmt.Errorf("log not found with name %v", n)
+}
+
+// LogResponse describes a single log returned by the management/logs endpoint.
+type LogResponse struct {
+	LogsetsInfo     []LogSetInfo `json:"logsets_info"`
+	Name            string       `json:"name"`
+	UserData        UserData     `json:"user_data"`
+	Tokens          []string     `json:"tokens"`
+	SourceType      string       `json:"source_type"`
+	TokenSeed       interface{}  `json:"token_seed"`
+	Structures      []string     `json:"structures"`
+	ID              string       `json:"id"`
+	RetentionPeriod string       `json:"retention_period"`
+	Links           []Link       `json:"links"`
+}
+
+// LogSetInfo describes a logset a log belongs to.
+type LogSetInfo struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Links []Link `json:"links"`
+}
+
+// Link is a HATEOAS style link returned by the logentries api.
+type Link struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+// UserData holds the le-agent metadata attached to a log.
+type UserData struct {
+	LeAgentFilename string `json:"le_agent_filename"`
+	LeAgentFollow   string `json:"le_agent_follow"`
+}
+
+//PostQueryRequest struct
+type PostQueryRequest struct {
+	Logs []string `json:"logs"`
+	Leql leql     `json:"leql"`
+}
+
+type leql struct {
+	During    during `json:"during"`
+	Statement string `json:"statement"`
+}
+
+type during struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// PostQueryResponse is the payload returned right after a query is posted.
+type PostQueryResponse struct {
+	Events   []string `json:"events"`
+	ID       string   `json:"id"`
+	Leql     leql     `json:"leql"`
+	Links    []Link   `json:"links"`
+	Logs     []string `json:"logs"`
+	Progress int32    `json:"progress"`
+}
+
+// GetQueryResponse is the payload returned while polling a query for results.
+type GetQueryResponse struct {
+	Events []Event  `json:"events"`
+	Leql   leql     `json:"leql"`
+	Links  []Link   `json:"links"`
+	Logs   []string `json:"logs"`
+}
+
+// Event is a single log event matched by a query.
+type Event struct {
+	Labels         []interface{} `json:"labels"`
+	Links          []Link        `json:"links"`
+	LogID          string        `json:"log_id"`
+	Message        string        `json:"message"`
+	SequenceNumber int64         `json:"sequence_number"`
+	Timestamp      int64         `json:"timestamp"`
+}
+
+// QueryEvent is an Event annotated with the name of the log it came from, for
+// callers aggregating events across more than one log.
+type QueryEvent struct {
+	Log            string `json:"log"`
+	Timestamp      int64  `json:"timestamp"`
+	Message        string `json:"message"`
+	SequenceNumber int64  `json:"sequence_number"`
+}
+
+// FetchLogs lists every log available for the configured api key.
+func (cmd *Command) FetchLogs(ctx context.Context) (*LogsResponse, error) {
+	var l = new(LogsResponse)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", logsURL, nil)
+	if err != nil {
+		return l, err
+	}
+
+	req.Header.Add("x-api-key", cmd.APIKey)
+
+	start := time.Now()
+	resp, err := cmd.Client.Do(req)
+
+	if err != nil {
+		return l, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	cmd.observe(req.Method, resp.StatusCode, time.Since(start), int64(len(body)))
+	if err != nil {
+		return l, err
+	}
+
+	err = json.Unmarshal(body, &l)
+
+	if err != nil {
+		return l, err
+	}
+
+	return l, err
+}
+
+// PostQuery starts a LEQL query against logID and returns the initial response,
+// which either contains the events right away or a link to poll for them.
+func (cmd *Command) PostQuery(ctx context.Context, logID string) (*PostQueryResponse, error) {
+	pqrr := new(PostQueryResponse)
+
+	from, err := convertDateToUnixTimestamp(cmd.StartDate)
+	if err != nil {
+		return pqrr, err
+	}
+
+	to, err := convertDateToUnixTimestamp(cmd.EndDate)
+	if err != nil {
+		return pqrr, err
+	}
+
+	pqr := NewPostQueryRequest(logID, cmd.Query, from, to)
+
+	b, err := json.Marshal(pqr)
+
+	if err != nil {
+		return pqrr, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", queryURL, bytes.NewBuffer(b))
+	if err != nil {
+		return pqrr, err
+	}
+	req.Header.Add("x-api-key", cmd.APIKey)
+	req.Header.Set("Content-type", "application/json")
+
+	start := time.Now()
+	resp, err := cmd.Client.Do(req)
+
+	if err != nil {
+		return pqrr, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	cmd.observe(req.Method, resp.StatusCode, time.Since(start), int64(len(body)))
+	if err != nil {
+		return pqrr, err
+
+	}
+
+	err = json.Unmarshal(body, &pqrr)
+
+	if err != nil {
+		return pqrr, err
+	}
+
+	return pqrr, nil
+}
+
+// GetLogMessages follows a poll link returned by PostQuery or a previous call to GetLogMessages.
+func (cmd *Command) GetLogMessages(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("x-api-key", cmd.APIKey)
+
+	resp, err := cmd.Client.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+
+}
+
+//NewPostQueryRequest create a new struct from this
+func NewPostQueryRequest(logID, statement string, from, to int64) *PostQueryRequest {
+	pqr := new(PostQueryRequest)
+	pqr.Logs = []string{
+		logID,
+	}
+	pqr.Leql = leql{
+		During: during{
+			From: from,
+			To:   to,
+		},
+		Statement: statement,
+	}
+	return pqr
+}
+
+// serializeData reads body into s and returns the number of bytes read, so
+// callers can report it as part of a request's stats regardless of whether
+// unmarshaling succeeded.
+func serializeData(body io.Reader, s interface{}) (int64, error) {
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return int64(len(b)), err
+	}
+
+	return int64(len(b)), json.Unmarshal(b, s)
+}
+
+// sleepContext blocks for d or returns ctx.Err() as soon as ctx is done,
+// whichever happens first, so a deadline can interrupt a pending poll wait
+// the same way it interrupts an in-flight request.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// HandleLogsEvents follows the poll link chain starting at url and returns
+// every event collected along the way, sleeping between polls for the
+// response's Retry-After delay when it carries one and sleepTime otherwise. It
+// returns as soon as ctx is cancelled or cmd.MaxPollDuration elapses.
+func (cmd *Command) HandleLogsEvents(ctx context.Context, url string) ([]Event, error) {
+	if cmd.MaxPollDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cmd.MaxPollDuration)
+		defer cancel()
+	}
+
+	var events []Event
+
+	for {
+		start := time.Now()
+
+		resp, err := cmd.GetLogMessages(ctx, url)
+		if err != nil {
+			return events, err
+		}
+
+		var links []Link
+
+		pollDelay := time.Duration(sleepTime) * time.Millisecond
+		if d, ok := httpclient.RetryAfter(resp); ok {
+			pollDelay = d
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			grr := new(GetQueryResponse)
+			n, err := serializeData(resp.Body, grr)
+			resp.Body.Close()
+			cmd.observe(http.MethodGet, resp.StatusCode, time.Since(start), n)
+			if err != nil {
+				return events, err
+			}
+
+			events = append(events, grr.Events...)
+			links = grr.Links
+		case http.StatusAccepted:
+			pqr := new(PostQueryResponse)
+			n, err := serializeData(resp.Body, pqr)
+			resp.Body.Close()
+			cmd.observe(http.MethodGet, resp.StatusCode, time.Since(start), n)
+			if err != nil {
+				return events, err
+			}
+			links = pqr.Links
+		default:
+			resp.Body.Close()
+			cmd.observe(http.MethodGet, resp.StatusCode, time.Since(start), 0)
+			return events, nil
+		}
+
+		if len(links) == 0 {
+			return events, nil
+		}
+
+		url = links[0].Href
+
+		if err := sleepContext(ctx, pollDelay); err != nil {
+			return events, err
+		}
+	}
+}
+
+// HandleLogs is HandleLogsEvents stripped down to just the event messages,
+// for callers that don't need the timestamp or sequence number.
+func (cmd *Command) HandleLogs(ctx context.Context, url string) ([]string, error) {
+	events, err := cmd.HandleLogsEvents(ctx, url)
+
+	messages := make([]string, 0, len(events))
+	for _, e := range events {
+		messages = append(messages, e.Message)
+	}
+
+	return messages, err
+}
+
+// Run fetches the log named by cmd.LogName, posts cmd.Query against it and
+// returns every message collected while following the resulting poll links.
+func (cmd *Command) Run(ctx context.Context) ([]string, error) {
+	lgs, err := cmd.FetchLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := lgs.GetLogByName(cmd.LogName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pqr, err := cmd.PostQuery(ctx, l.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	links := pqr.Links
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	return cmd.HandleLogs(ctx, links[0].Href)
+}
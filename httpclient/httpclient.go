@@ -0,0 +1,163 @@
+// Package httpclient wraps the request/response lifecycle used by the gle
+// client package with retries, jittered exponential backoff and Retry-After
+// awareness, so client.Command's FetchLogs/PostQuery/GetLogMessages don't
+// have to hand-roll it and can be exercised in tests through a mock
+// http.RoundTripper.
+package httpclient
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBaseDelay  = 200 * time.Millisecond
+	defaultMaxDelay   = 5 * time.Second
+)
+
+// Client wraps an http.Client, retrying idempotent GETs with jittered
+// exponential backoff on network errors and 5xx responses.
+type Client struct {
+	HTTPClient http.Client
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+
+	// Verbose logs every request/response (or error) through the standard
+	// logger, for the cli's --verbose flag.
+	Verbose bool
+}
+
+// New creates a Client with sane retry defaults.
+func New() *Client {
+	return &Client{
+		MaxRetries: defaultMaxRetries,
+		BaseDelay:  defaultBaseDelay,
+		MaxDelay:   defaultMaxDelay,
+	}
+}
+
+// SetRoundTripper swaps the underlying transport, so callers can point Do at
+// a mock RoundTripper in tests instead of the network.
+func (c *Client) SetRoundTripper(rt http.RoundTripper) {
+	c.HTTPClient.Transport = rt
+}
+
+// Do sends req, retrying on network errors, 429 and 5xx responses when req
+// is a GET, up to c.MaxRetries times (defaultMaxRetries when c.MaxRetries is
+// not positive). The delay between
+// attempts honors the response's Retry-After header when present, falling
+// back to jittered exponential backoff otherwise.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	maxRetries := c.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	var resp *http.Response
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		if c.Verbose {
+			log.Printf("httpclient: %s %s (attempt %d)", req.Method, req.URL, attempt+1)
+		}
+
+		resp, err = c.HTTPClient.Do(req)
+
+		if c.Verbose {
+			if err != nil {
+				log.Printf("httpclient: %s %s error: %v", req.Method, req.URL, err)
+			} else {
+				log.Printf("httpclient: %s %s -> %d", req.Method, req.URL, resp.StatusCode)
+			}
+		}
+
+		if !retryableMethod(req.Method) || attempt >= maxRetries || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := c.backoff(attempt)
+		if d, ok := RetryAfter(resp); ok {
+			delay = d
+		}
+
+		drainAndClose(resp)
+
+		if werr := sleep(req.Context(), delay); werr != nil {
+			return nil, werr
+		}
+	}
+}
+
+func retryableMethod(method string) bool {
+	return method == http.MethodGet || method == ""
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+func drainAndClose(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// backoff returns a jittered exponential delay for the given attempt,
+// capped at c.MaxDelay.
+func (c *Client) backoff(attempt int) time.Duration {
+	base := c.BaseDelay
+	if base <= 0 {
+		base = defaultBaseDelay
+	}
+
+	max := c.MaxDelay
+	if max <= 0 {
+		max = defaultMaxDelay
+	}
+
+	d := base << uint(attempt)
+	if d <= 0 || d > max {
+		d = max
+	}
+
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// RetryAfter extracts and parses the Retry-After header from resp, which the
+// Logentries api sets on 429 and 503 responses. It supports both the
+// delay-seconds and HTTP-date forms; ok is false when the header is absent
+// or unparseable.
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func newResponse(status int, header http.Header) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+}
+
+func TestDoRetriesOn5xx(t *testing.T) {
+	c := New()
+	c.BaseDelay = time.Millisecond
+	c.MaxDelay = 2 * time.Millisecond
+
+	attempts := 0
+	c.SetRoundTripper(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts < 3 {
+			return newResponse(http.StatusServiceUnavailable, nil), nil
+		}
+		return newResponse(http.StatusOK, nil), nil
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected final status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestDoRetriesOn429WithRetryAfter(t *testing.T) {
+	c := New()
+	c.BaseDelay = time.Second
+	c.MaxDelay = time.Second
+
+	attempts := 0
+	c.SetRoundTripper(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts < 2 {
+			header := http.Header{}
+			header.Set("Retry-After", "0")
+			return newResponse(http.StatusTooManyRequests, header), nil
+		}
+		return newResponse(http.StatusOK, nil), nil
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected final status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed >= c.BaseDelay {
+		t.Fatalf("retry took %v, expected Retry-After (0s) to override BaseDelay (%v)", elapsed, c.BaseDelay)
+	}
+}
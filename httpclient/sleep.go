@@ -0,0 +1,20 @@
+package httpclient
+
+import (
+	"context"
+	"time"
+)
+
+// sleep blocks for d or returns ctx.Err() as soon as ctx is done, whichever
+// happens first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
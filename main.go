@@ -1,390 +1,561 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/rfunix/gle/client"
+	"github.com/rfunix/gle/httpclient"
+	"github.com/rfunix/gle/rules"
+	"github.com/rfunix/gle/server"
+	"github.com/rfunix/gle/stats"
 	"github.com/urfave/cli"
 )
 
 const version = "0.1.1"
-const dateLayout = "2006-01-02 15:04:05"
-const sleepTime = 1000
 
-func convertDateToUnixTimestamp(d string) (int64, error) {
-	t, error := time.Parse(dateLayout, d)
-	if error != nil {
-		return 0, error
-	}
+func main() {
+	cmd := client.Command{}
+
+	var timeout time.Duration
+	var logNames cli.StringSlice
+	var logRegex string
+	var concurrency int
+	var limit int
+	var output string
+	var verbose bool
+	var statsLevel string
+	var statsFile string
+	var metricsAddr string
 
-	ut := t.UnixNano() / int64(time.Millisecond)
+	app := cli.NewApp()
 
-	return ut, nil
-}
+	app.Name = "gle"
+	app.Usage = "logentries cli tool"
+	app.Version = version
 
-func prettyPrintJSON(j interface{}) {
-	json, err := json.MarshalIndent(j, "", "    ")
-	if err != nil {
-		log.Fatal(err)
+	app.Flags = []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "log, l",
+			Usage: "Name of log(s) in logentries, comma separated or repeated",
+			Value: &logNames,
+		},
+		cli.StringFlag{
+			Name:        "log-regex",
+			Usage:       "regular expression matched against every log name known to logentries, in addition to --log",
+			Destination: &logRegex,
+		},
+		cli.StringFlag{
+			Name:        "api-key",
+			Usage:       "The logentries api-key, its recomend export envvar with name X_API_KEY",
+			Destination: &cmd.APIKey,
+			EnvVar:      "X_API_KEY",
+		},
+		cli.StringFlag{
+			Name:        "start-date",
+			Usage:       "The start date period to search log",
+			Destination: &cmd.StartDate,
+		},
+		cli.StringFlag{
+			Name:        "end-date",
+			Usage:       "The end date period to search log",
+			Destination: &cmd.EndDate,
+		},
+		cli.StringFlag{
+			Name:        "query",
+			Usage:       "the query to search pattern",
+			Destination: &cmd.Query,
+		},
+		cli.DurationFlag{
+			Name:        "timeout",
+			Usage:       "overall deadline for fetching logs, posting the query and polling for results",
+			Destination: &timeout,
+		},
+		cli.DurationFlag{
+			Name:        "max-poll-duration",
+			Usage:       "deadline for following poll links after the query has been posted",
+			Destination: &cmd.MaxPollDuration,
+		},
+		cli.IntFlag{
+			Name:        "concurrency",
+			Usage:       "number of logs to query at the same time",
+			Value:       4,
+			Destination: &concurrency,
+		},
+		cli.IntFlag{
+			Name:        "limit",
+			Usage:       "stop after this many events across every log, 0 for unlimited",
+			Destination: &limit,
+		},
+		cli.StringFlag{
+			Name:        "output",
+			Usage:       "output format: text, ndjson or csv",
+			Value:       "text",
+			Destination: &output,
+		},
+		cli.BoolFlag{
+			Name:        "verbose",
+			Usage:       "log every request and response made to the logentries api",
+			Destination: &verbose,
+		},
+		cli.StringFlag{
+			Name:        "stats",
+			Usage:       "query stats to report on stderr: none, summary or all",
+			Value:       "none",
+			Destination: &statsLevel,
+		},
+		cli.StringFlag{
+			Name:        "stats-file",
+			Usage:       "NDJSON file the all stats level appends a per-poll breakdown to",
+			Destination: &statsFile,
+		},
+		cli.StringFlag{
+			Name:        "metrics-addr",
+			Usage:       "address to serve Prometheus metrics on, disabled when empty",
+			Destination: &metricsAddr,
+		},
 	}
 
-	fmt.Println(string(json))
-}
+	app.Action = func(c *cli.Context) error {
+		if err := validateQueryFlags(&cmd); err != nil {
+			return err
+		}
 
-type command struct {
-	logName   string
-	apiKey    string
-	startDate string
-	endDate   string
-	query     string
-	client    http.Client
-}
+		ctx, cancel := newContext(timeout)
+		defer cancel()
 
-type logsResponse struct {
-	Logs []logResponse `json:"logs"`
-}
+		var metrics *stats.Metrics
+		if metricsAddr != "" {
+			metrics = stats.NewMetrics()
 
-func (lr *logsResponse) getLogByName(n string) (logResponse, error) {
-	for _, l := range lr.Logs {
-		if l.Name == n {
-			return l, nil
+			go func() {
+				log.Printf("gle metrics listening on %v", metricsAddr)
+				if err := http.ListenAndServe(metricsAddr, metrics.Handler()); err != nil {
+					log.Printf("metrics server stopped: %v", err)
+				}
+			}()
 		}
-	}
-	return logResponse{}, fmt.Errorf("log not found with name %v", n)
-}
-
-type logResponse struct {
-	LogsetsInfo     []logSetInfo `json:"logsets_info"`
-	Name            string       `json:"name"`
-	UserData        userData     `json:"user_data"`
-	Tokens          []string     `json:"tokens"`
-	SourceType      string       `json:"source_type"`
-	TokenSeed       interface{}  `json:"token_seed"`
-	Structures      []string     `json:"structures"`
-	ID              string       `json:"id"`
-	RetentionPeriod string       `json:"retention_period"`
-	Links           []link       `json:"links"`
-}
 
-type logSetInfo struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Links []link `json:"links"`
-}
+		reporter := &stats.Reporter{Level: statsLevel, File: statsFile}
 
-type link struct {
-	Href string `json:"href"`
-	Rel  string `json:"rel"`
-}
+		return runMulti(ctx, &cmd, splitLogNames(logNames), logRegex, output, concurrency, limit, verbose, reporter, metrics)
+	}
 
-type userData struct {
-	LeAgentFilename string `json:"le_agent_filename"`
-	LeAgentFollow   string `json:"le_agent_follow"`
-}
+	var serverAddr string
+	var serverAPIKey string
+	var serverRulesFile string
+	var serverPersistFile string
+
+	var rulesAPIKey string
+	var rulesFile string
+	var rulesPersistFile string
+
+	app.Commands = []cli.Command{
+		{
+			Name:  "server",
+			Usage: "starts gle as an HTTP REST API service",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "addr",
+					Usage:       "address the HTTP server listens on",
+					Value:       ":8080",
+					Destination: &serverAddr,
+				},
+				cli.StringFlag{
+					Name:        "api-key",
+					Usage:       "The logentries api-key, its recomend export envvar with name X_API_KEY",
+					Destination: &serverAPIKey,
+					Required:    true,
+					EnvVar:      "X_API_KEY",
+				},
+				cli.StringFlag{
+					Name:        "rules-file",
+					Usage:       "YAML file of alert rules to evaluate and expose on /api/v1/rules and /api/v1/alerts",
+					Destination: &serverRulesFile,
+				},
+				cli.StringFlag{
+					Name:        "rules-persist-file",
+					Usage:       "file storing each rule's last-fired timestamp across restarts",
+					Destination: &serverPersistFile,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return runServer(serverAddr, serverAPIKey, serverRulesFile, serverPersistFile)
+			},
+		},
+		{
+			Name:  "rules",
+			Usage: "evaluates a YAML file of alert rules and fires notifications",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "api-key",
+					Usage:       "The logentries api-key, its recomend export envvar with name X_API_KEY",
+					Destination: &rulesAPIKey,
+					Required:    true,
+					EnvVar:      "X_API_KEY",
+				},
+				cli.StringFlag{
+					Name:        "rules-file",
+					Usage:       "YAML file of alert rules to evaluate",
+					Destination: &rulesFile,
+					Required:    true,
+				},
+				cli.StringFlag{
+					Name:        "persist-file",
+					Usage:       "file storing each rule's last-fired timestamp across restarts",
+					Destination: &rulesPersistFile,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer stop()
+
+				return runRules(ctx, rulesAPIKey, rulesFile, rulesPersistFile)
+			},
+		},
+	}
 
-//PostQueryRequest struct
-type PostQueryRequest struct {
-	Logs []string `json:"logs"`
-	Leql leql     `json:"leql"`
+	err := app.Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
-type leql struct {
-	During    during `json:"during"`
-	Statement string `json:"statement"`
-}
+// validateQueryFlags checks the flags the default action needs that can't be
+// marked Required at the app level, since urfave/cli v1 enforces Required
+// global flags before dispatching to a subcommand, which would make
+// server/rules unstartable.
+func validateQueryFlags(cmd *client.Command) error {
+	var missing []string
 
-type during struct {
-	From int64 `json:"from"`
-	To   int64 `json:"to"`
-}
+	if cmd.APIKey == "" {
+		missing = append(missing, "api-key")
+	}
+	if cmd.StartDate == "" {
+		missing = append(missing, "start-date")
+	}
+	if cmd.EndDate == "" {
+		missing = append(missing, "end-date")
+	}
+	if cmd.Query == "" {
+		missing = append(missing, "query")
+	}
 
-type postQueryResponse struct {
-	Events   []string `json:"events"`
-	ID       string   `json:"id"`
-	Leql     leql     `json:"leql"`
-	Links    []link   `json:"links"`
-	Logs     []string `json:"logs"`
-	Progress int32    `json:"progress"`
-}
+	if len(missing) > 0 {
+		return fmt.Errorf("required flags %q not set", strings.Join(missing, ", "))
+	}
 
-type getQueryResponse struct {
-	Events []event  `json:"events"`
-	Leql   leql     `json:"leql"`
-	Links  []link   `json:"links"`
-	Logs   []string `json:"logs"`
+	return nil
 }
 
-type event struct {
-	Labels         []interface{} `json:"labels"`
-	Links          []link        `json:"links"`
-	LogID          string        `json:"log_id"`
-	Message        string        `json:"message"`
-	SequenceNumber int64         `json:"sequence_number"`
-	Timestamp      int64         `json:"timestamp"`
+// splitLogNames lets --log be repeated and/or comma separated.
+func splitLogNames(names cli.StringSlice) []string {
+	var split []string
+	for _, n := range names {
+		for _, part := range strings.Split(n, ",") {
+			part = strings.TrimSpace(part)
+			if part != "" {
+				split = append(split, part)
+			}
+		}
+	}
+	return split
 }
 
-func (cmd *command) fetchLogs() (*logsResponse, error) {
-	var l = new(logsResponse)
+// newContext builds the context the cli action runs under: cancelled on
+// os.Interrupt so Ctrl-C aborts an in-flight query cleanly, and additionally
+// bounded by timeout when one is set.
+func newContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	if timeout <= 0 {
+		return ctx, stop
+	}
 
-	req, err := http.NewRequest("GET", "https://rest.logentries.com/management/logs", nil)
-	if err != nil {
-		return l, err
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
 	}
+}
 
-	req.Header.Add("x-api-key", cmd.apiKey)
+// logJob is one log queried by runMulti's worker pool.
+type logJob struct {
+	name string
+	id   string
+}
 
-	resp, err := cmd.client.Do(req)
+// logFailure records the error returned while querying a single log so it
+// can be surfaced in the final summary instead of aborting every other log.
+type logFailure struct {
+	log string
+	err error
+}
 
-	if err != nil {
-		return l, err
+// runMulti fans out PostQuery/HandleLogsEvents across --concurrency workers
+// sharing one httpclient.Client (and therefore one connection pool), merges
+// their events through a single aggregator goroutine and prints them as
+// text, ndjson or csv.
+func runMulti(ctx context.Context, tmpl *client.Command, logNames []string, logRegex, output string, concurrency, limit int, verbose bool, reporter *stats.Reporter, metrics *stats.Metrics) error {
+	if concurrency <= 0 {
+		concurrency = 1
 	}
 
-	defer resp.Body.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return l, err
-	}
+	shared := httpclient.New()
+	shared.Verbose = verbose
+	shared.HTTPClient.Transport = &http.Transport{MaxIdleConnsPerHost: concurrency}
 
-	err = json.Unmarshal(body, &l)
+	lister := client.NewCommand("", tmpl.APIKey, tmpl.StartDate, tmpl.EndDate, tmpl.Query)
+	lister.Client = shared
 
+	lgs, err := lister.FetchLogs(ctx)
 	if err != nil {
-		return l, err
+		return err
 	}
 
-	return l, err
-}
-
-func (cmd *command) postQuery(logID string) (*postQueryResponse, error) {
-	pqrr := new(postQueryResponse)
-
-	from, err := convertDateToUnixTimestamp(cmd.startDate)
+	jobs, err := resolveLogJobs(lgs, logNames, logRegex)
 	if err != nil {
-		return pqrr, err
+		return err
 	}
 
-	to, err := convertDateToUnixTimestamp(cmd.endDate)
-	if err != nil {
-		return pqrr, err
+	jobCh := make(chan logJob)
+	resultCh := make(chan client.QueryEvent, concurrency*4)
+	failureCh := make(chan logFailure, len(jobs))
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := range jobCh {
+				if ctx.Err() != nil {
+					continue
+				}
+				queryLog(ctx, tmpl, shared, j, resultCh, failureCh, reporter, metrics)
+			}
+		}()
 	}
 
-	pqr := NewPostQueryRequest(logID, cmd.query, from, to)
+	go func() {
+		for _, j := range jobs {
+			jobCh <- j
+		}
+		close(jobCh)
 
-	b, err := json.Marshal(pqr)
+		workers.Wait()
+		close(resultCh)
+		close(failureCh)
+	}()
 
-	if err != nil {
-		return pqrr, err
+	if err := printResults(resultCh, output, limit, cancel); err != nil {
+		return err
 	}
 
-	req, err := http.NewRequest("POST", "https://rest.logentries.com/query/logs/", bytes.NewBuffer(b))
-	req.Header.Add("x-api-key", cmd.apiKey)
-	req.Header.Set("Content-type", "application/json")
-
-	if err != nil {
-		return pqrr, err
+	var failures []logFailure
+	for f := range failureCh {
+		failures = append(failures, f)
 	}
 
-	resp, err := cmd.client.Do(req)
-
-	if err != nil {
-		return pqrr, err
+	if len(failures) > 0 {
+		fmt.Fprintln(os.Stderr, "gle: some logs failed:")
+		for _, f := range failures {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", f.log, f.err)
+		}
 	}
 
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return pqrr, err
+	return nil
+}
 
-	}
+// queryLog posts the query against a single log and streams every resulting
+// event onto resultCh in SequenceNumber order, backpressuring the worker
+// (and therefore the rest of the pool) whenever resultCh's consumer stalls.
+func queryLog(ctx context.Context, tmpl *client.Command, shared *httpclient.Client, j logJob, resultCh chan<- client.QueryEvent, failureCh chan<- logFailure, reporter *stats.Reporter, metrics *stats.Metrics) {
+	cmd := client.NewCommand(j.name, tmpl.APIKey, tmpl.StartDate, tmpl.EndDate, tmpl.Query)
+	cmd.Client = shared
+	cmd.MaxPollDuration = tmpl.MaxPollDuration
 
-	err = json.Unmarshal(body, &pqrr)
+	recorder := stats.NewRecorder(j.name)
+	cmd.OnRequest = recorder.Observe
 
+	pqr, err := cmd.PostQuery(ctx, j.id)
 	if err != nil {
-		return pqrr, err
+		failureCh <- logFailure{log: j.name, err: err}
+		return
 	}
 
-	return pqrr, nil
-}
-
-func (cmd *command) getLogMessages(url string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+	var events []client.Event
+	if len(pqr.Links) > 0 {
+		events, err = cmd.HandleLogsEvents(ctx, pqr.Links[0].Href)
+		if err != nil {
+			failureCh <- logFailure{log: j.name, err: err}
+		}
 	}
 
-	req.Header.Add("x-api-key", cmd.apiKey)
+	reportStats(recorder, len(events), reporter, metrics)
 
-	resp, err := cmd.client.Do(req)
+	sort.Slice(events, func(i, j int) bool { return events[i].SequenceNumber < events[j].SequenceNumber })
 
-	if err != nil {
-		return nil, err
+	for _, e := range events {
+		select {
+		case resultCh <- client.QueryEvent{Log: j.name, Timestamp: e.Timestamp, Message: e.Message, SequenceNumber: e.SequenceNumber}:
+		case <-ctx.Done():
+			return
+		}
 	}
-
-	return resp, nil
-
 }
 
-//NewPostQueryRequest create a new struct from this
-func NewPostQueryRequest(logID, statement string, from, to int64) *PostQueryRequest {
-	pqr := new(PostQueryRequest)
-	pqr.Logs = []string{
-		logID,
+// reportStats finishes recorder and feeds the resulting Query to reporter
+// and metrics, whichever of the two are enabled.
+func reportStats(recorder *stats.Recorder, events int, reporter *stats.Reporter, metrics *stats.Metrics) {
+	q := recorder.Finish(events)
+
+	if reporter != nil {
+		if err := reporter.Report(q); err != nil {
+			log.Printf("gle: failed to report stats for %s: %v", q.Log, err)
+		}
 	}
-	pqr.Leql = leql{
-		During: during{
-			From: from,
-			To:   to,
-		},
-		Statement: statement,
+
+	if metrics != nil {
+		metrics.Record(q)
 	}
-	return pqr
 }
 
-func main() {
-	cmd := command{}
-
-	app := cli.NewApp()
-
-	app.Name = "gle"
-	app.Usage = "logentries cli tool"
-	app.Version = version
+// resolveLogJobs expands explicit --log names and --log-regex matches into
+// the set of logs to query, each paired with its logentries id.
+func resolveLogJobs(lgs *client.LogsResponse, names []string, pattern string) ([]logJob, error) {
+	seen := map[string]bool{}
+	var jobs []logJob
 
-	app.Flags = []cli.Flag{
-		cli.StringFlag{
-			Name:        "log, l",
-			Usage:       "Name of log in logentries",
-			Destination: &cmd.logName,
-			Required:    true,
-		},
-		cli.StringFlag{
-			Name:        "api-key",
-			Usage:       "The logentries api-key, its recomend export envvar with name X_API_KEY",
-			Destination: &cmd.apiKey,
-			Required:    true,
-			EnvVar:      "X_API_KEY",
-		},
-		cli.StringFlag{
-			Name:        "start-date",
-			Usage:       "The start date period to search log",
-			Destination: &cmd.startDate,
-			Required:    true,
-		},
-		cli.StringFlag{
-			Name:        "end-date",
-			Usage:       "The end date period to search log",
-			Destination: &cmd.endDate,
-			Required:    true,
-		},
-		cli.StringFlag{
-			Name:        "query",
-			Usage:       "the query to search pattern",
-			Destination: &cmd.query,
-			Required:    true,
-		},
-	}
+	add := func(name string) error {
+		if seen[name] {
+			return nil
+		}
 
-	app.Action = func(c *cli.Context) error {
-		if err := run(&cmd); err != nil {
+		l, err := lgs.GetLogByName(name)
+		if err != nil {
 			return err
 		}
+
+		seen[name] = true
+		jobs = append(jobs, logJob{name: name, id: l.ID})
 		return nil
 	}
 
-	err := app.Run(os.Args)
-	if err != nil {
-		log.Fatal(err)
+	for _, n := range names {
+		if err := add(n); err != nil {
+			return nil, err
+		}
 	}
-}
 
-func (cmd *command) handleLogs(url string) {
-	serializeData := func(body io.Reader, s interface{}) (interface{}, error) {
-		b, err := ioutil.ReadAll(body)
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
 		if err != nil {
-			return s, err
-
+			return nil, err
 		}
 
-		err = json.Unmarshal(b, &s)
-
-		if err != nil {
-			return s, err
+		for _, l := range lgs.Logs {
+			if re.MatchString(l.Name) {
+				add(l.Name)
+			}
 		}
-
-		return s, nil
 	}
 
-	resp, err := cmd.getLogMessages(url)
-
-	if err != nil {
-		log.Fatal(err)
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no logs matched --log or --log-regex")
 	}
 
-	switch resp.StatusCode {
-	case http.StatusOK:
-		grr := new(getQueryResponse)
-		d, err := serializeData(resp.Body, grr)
-		if err != nil {
-			log.Fatal(err)
-		}
+	return jobs, nil
+}
 
-		for _, m := range d.(*getQueryResponse).Events {
-			fmt.Println(m.Message)
+// printResults drains resultCh, printing up to limit events (0 means
+// unlimited) in the requested output format. Once limit is reached it calls
+// cancel so workers stop polling instead of just going unprinted, and keeps
+// draining past limit so that queryLog's back-pressured sends never block
+// forever in the meantime.
+func printResults(resultCh <-chan client.QueryEvent, output string, limit int, cancel context.CancelFunc) error {
+	var csvw *csv.Writer
+	if output == "csv" {
+		csvw = csv.NewWriter(os.Stdout)
+		if err := csvw.Write([]string{"log", "timestamp", "sequence_number", "message"}); err != nil {
+			return err
 		}
+		defer csvw.Flush()
+	}
 
-		links := d.(*getQueryResponse).Links
-		if len(links) > 0 {
-			newURL := d.(*getQueryResponse).Links[0].Href
-			time.Sleep(sleepTime * time.Millisecond)
-			cmd.handleLogs(newURL)
+	count := 0
+	for e := range resultCh {
+		if limit > 0 && count >= limit {
+			continue
 		}
-	case http.StatusAccepted:
-		pqr := new(postQueryResponse)
-		d, err := serializeData(resp.Body, pqr)
-		if err != nil {
-			log.Fatal(err)
+		count++
+
+		switch output {
+		case "ndjson":
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+		case "csv":
+			if err := csvw.Write([]string{e.Log, strconv.FormatInt(e.Timestamp, 10), strconv.FormatInt(e.SequenceNumber, 10), e.Message}); err != nil {
+				return err
+			}
+		default:
+			fmt.Println(e.Message)
 		}
-		links := d.(*postQueryResponse).Links
-		if len(links) > 0 {
-			newURL := d.(*postQueryResponse).Links[0].Href
-			time.Sleep(sleepTime * time.Millisecond)
-			cmd.handleLogs(newURL)
+
+		if limit > 0 && count >= limit {
+			cancel()
 		}
-	default:
-		return
 	}
 
+	return nil
 }
 
-func run(cmd *command) error {
-	lgs, err := cmd.fetchLogs()
-	if err != nil {
-		return err
-	}
+func runServer(addr, apiKey, rulesFile, persistFile string) error {
+	s := server.New(apiKey)
 
-	l, err := lgs.getLogByName(cmd.logName)
+	if rulesFile != "" {
+		rs, notifiers, err := rules.LoadRules(rulesFile)
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
+		m := rules.NewManager(apiKey, persistFile, rs, notifiers...)
+		s.SetRuleManager(m)
+
+		go func() {
+			if err := m.Run(context.Background()); err != nil {
+				log.Printf("rules manager stopped: %v", err)
+			}
+		}()
 	}
 
-	pqr, err := cmd.postQuery(l.ID)
+	log.Printf("gle server listening on %v", addr)
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+func runRules(ctx context.Context, apiKey, rulesFile, persistFile string) error {
+	rs, notifiers, err := rules.LoadRules(rulesFile)
 	if err != nil {
 		return err
 	}
 
-	links := pqr.Links
-	if len(links) > 0 {
-		url := links[0].Href
-		cmd.handleLogs(url)
-	}
+	m := rules.NewManager(apiKey, persistFile, rs, notifiers...)
 
-	return nil
+	return m.Run(ctx)
 }
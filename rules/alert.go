@@ -0,0 +1,28 @@
+package rules
+
+import "time"
+
+// Alert is a point-in-time snapshot of a rule that is Pending or Firing.
+type Alert struct {
+	Rule        string            `json:"rule"`
+	Log         string            `json:"log"`
+	State       string            `json:"state"`
+	Value       int               `json:"value"`
+	ActiveSince time.Time         `json:"active_since"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// RuleStatus is the JSON shape returned by GET /api/v1/rules.
+type RuleStatus struct {
+	Name        string            `json:"name"`
+	Log         string            `json:"log"`
+	Query       string            `json:"query"`
+	State       string            `json:"state"`
+	Threshold   int               `json:"threshold"`
+	LastValue   int               `json:"last_value"`
+	ActiveSince *time.Time        `json:"active_since,omitempty"`
+	LastFired   *time.Time        `json:"last_fired,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
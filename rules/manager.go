@@ -0,0 +1,245 @@
+package rules
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rfunix/gle/client"
+)
+
+// Manager evaluates a set of Rules on their own interval and notifies
+// Notifiers whenever one transitions into the Firing state.
+type Manager struct {
+	apiKey      string
+	persistPath string
+	notifiers   []Notifier
+
+	mu    sync.RWMutex
+	rules []*Rule
+}
+
+// NewManager creates a Manager that evaluates rules against the given
+// logentries api key, persisting last-fired timestamps to persistPath
+// (ignored when empty) so they survive restarts.
+func NewManager(apiKey, persistPath string, rules []*Rule, notifiers ...Notifier) *Manager {
+	return &Manager{
+		apiKey:      apiKey,
+		persistPath: persistPath,
+		notifiers:   notifiers,
+		rules:       rules,
+	}
+}
+
+// Run evaluates every rule on its own ticker until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) error {
+	lastFired, err := loadLastFired(m.persistPath)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	for _, r := range m.rules {
+		if t, ok := lastFired[r.Name]; ok {
+			// Restore as already Firing so the first evaluation after a
+			// restart, if still above threshold, doesn't notify again for a
+			// condition that was already firing when we went down.
+			r.lastFired = t
+			r.state = StateFiring
+			r.activeSince = t
+		}
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, r := range m.rules {
+		wg.Add(1)
+		go func(r *Rule) {
+			defer wg.Done()
+			m.runRule(ctx, r)
+		}(r)
+	}
+
+	wg.Wait()
+	return nil
+}
+
+func (m *Manager) runRule(ctx context.Context, r *Rule) {
+	ticker := time.NewTicker(time.Duration(r.Interval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.evaluate(ctx, r); err != nil {
+				log.Printf("rules: %s: %v", r.Name, err)
+			}
+		}
+	}
+}
+
+func (m *Manager) evaluate(ctx context.Context, r *Rule) error {
+	now := time.Now()
+
+	cmd := client.NewCommand(r.Log, m.apiKey, now.Add(-time.Duration(r.Interval)).Format("2006-01-02 15:04:05"), now.Format("2006-01-02 15:04:05"), r.Query)
+
+	lgs, err := cmd.FetchLogs(ctx)
+	if err != nil {
+		return err
+	}
+
+	l, err := lgs.GetLogByName(r.Log)
+	if err != nil {
+		return err
+	}
+
+	pqr, err := cmd.PostQuery(ctx, l.ID)
+	if err != nil {
+		return err
+	}
+
+	var events []string
+	if len(pqr.Links) > 0 {
+		events, err = cmd.HandleLogs(ctx, pqr.Links[0].Href)
+		if err != nil {
+			return err
+		}
+	} else {
+		events = pqr.Events
+	}
+
+	return m.transition(r, len(events), now)
+}
+
+// transition applies the Inactive->Pending->Firing state machine for a
+// single evaluation and notifies only on the edge into Firing, not on every
+// evaluation a sustained condition stays Firing.
+func (m *Manager) transition(r *Rule, count int, now time.Time) error {
+	m.mu.Lock()
+
+	prevState := r.state
+	r.lastValue = count
+
+	switch {
+	case count <= r.Threshold:
+		r.state = StateInactive
+		r.activeSince = time.Time{}
+	case r.state == StateInactive:
+		r.state = StatePending
+		r.activeSince = now
+	case r.state == StatePending && now.Sub(r.activeSince) >= time.Duration(r.For):
+		r.state = StateFiring
+	}
+
+	newlyFiring := r.state == StateFiring && prevState != StateFiring
+	if newlyFiring {
+		r.lastFired = now
+	}
+
+	alert := Alert{
+		Rule:        r.Name,
+		Log:         r.Log,
+		State:       r.state.String(),
+		Value:       count,
+		ActiveSince: r.activeSince,
+		Annotations: r.Annotations,
+		Labels:      r.Labels,
+	}
+
+	m.mu.Unlock()
+
+	if !newlyFiring {
+		return nil
+	}
+
+	if err := m.persist(); err != nil {
+		log.Printf("rules: failed to persist last-fired state: %v", err)
+	}
+
+	for _, n := range m.notifiers {
+		if err := n.Notify(alert); err != nil {
+			log.Printf("rules: %s: notifier error: %v", r.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) persist() error {
+	m.mu.RLock()
+	state := lastFiredState{}
+	for _, r := range m.rules {
+		if !r.lastFired.IsZero() {
+			state[r.Name] = r.lastFired
+		}
+	}
+	m.mu.RUnlock()
+
+	return saveLastFired(m.persistPath, state)
+}
+
+// Rules returns the current status of every rule managed by m.
+func (m *Manager) Rules() []RuleStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]RuleStatus, 0, len(m.rules))
+	for _, r := range m.rules {
+		statuses = append(statuses, ruleStatus(r))
+	}
+
+	return statuses
+}
+
+// Alerts returns every rule that is currently Pending or Firing.
+func (m *Manager) Alerts() []Alert {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var alerts []Alert
+	for _, r := range m.rules {
+		if r.state == StateInactive {
+			continue
+		}
+
+		alerts = append(alerts, Alert{
+			Rule:        r.Name,
+			Log:         r.Log,
+			State:       r.state.String(),
+			Value:       r.lastValue,
+			ActiveSince: r.activeSince,
+			Annotations: r.Annotations,
+			Labels:      r.Labels,
+		})
+	}
+
+	return alerts
+}
+
+func ruleStatus(r *Rule) RuleStatus {
+	status := RuleStatus{
+		Name:        r.Name,
+		Log:         r.Log,
+		Query:       r.Query,
+		State:       r.state.String(),
+		Threshold:   r.Threshold,
+		LastValue:   r.lastValue,
+		Annotations: r.Annotations,
+		Labels:      r.Labels,
+	}
+
+	if !r.activeSince.IsZero() {
+		t := r.activeSince
+		status.ActiveSince = &t
+	}
+
+	if !r.lastFired.IsZero() {
+		t := r.lastFired
+		status.LastFired = &t
+	}
+
+	return status
+}
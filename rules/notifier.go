@@ -0,0 +1,153 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Notifier delivers a firing Alert to an external system.
+type Notifier interface {
+	Notify(a Alert) error
+}
+
+// NotifierConfig is the notifiers: section of the rules YAML file. Each
+// non-nil field builds and enables the corresponding Notifier.
+type NotifierConfig struct {
+	Webhook   *WebhookNotifierConfig   `yaml:"webhook"`
+	Slack     *SlackNotifierConfig     `yaml:"slack"`
+	PagerDuty *PagerDutyNotifierConfig `yaml:"pagerduty"`
+}
+
+// WebhookNotifierConfig configures a WebhookNotifier.
+type WebhookNotifierConfig struct {
+	URL string `yaml:"url"`
+}
+
+// SlackNotifierConfig configures a SlackNotifier.
+type SlackNotifierConfig struct {
+	WebhookURL string `yaml:"webhook_url"`
+}
+
+// PagerDutyNotifierConfig configures a PagerDutyNotifier.
+type PagerDutyNotifierConfig struct {
+	RoutingKey string `yaml:"routing_key"`
+}
+
+// Build returns the Notifiers enabled by c, in webhook, Slack, PagerDuty order.
+func (c NotifierConfig) Build() []Notifier {
+	var notifiers []Notifier
+
+	if c.Webhook != nil {
+		notifiers = append(notifiers, &WebhookNotifier{URL: c.Webhook.URL})
+	}
+	if c.Slack != nil {
+		notifiers = append(notifiers, &SlackNotifier{WebhookURL: c.Slack.WebhookURL})
+	}
+	if c.PagerDuty != nil {
+		notifiers = append(notifiers, &PagerDutyNotifier{RoutingKey: c.PagerDuty.RoutingKey})
+	}
+
+	return notifiers
+}
+
+// WebhookNotifier POSTs the alert as JSON to a generic webhook URL.
+type WebhookNotifier struct {
+	URL    string
+	Client http.Client
+}
+
+// Notify implements Notifier.
+func (n *WebhookNotifier) Notify(a Alert) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.Client.Post(n.URL, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook notifier: unexpected status %v", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SlackNotifier posts the alert to a Slack incoming webhook.
+type SlackNotifier struct {
+	WebhookURL string
+	Client     http.Client
+}
+
+// Notify implements Notifier.
+func (n *SlackNotifier) Notify(a Alert) error {
+	payload := struct {
+		Text string `json:"text"`
+	}{
+		Text: fmt.Sprintf("[%s] %s on %s: %d events (since %s)", a.State, a.Rule, a.Log, a.Value, a.ActiveSince.Format("2006-01-02 15:04:05")),
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.Client.Post(n.WebhookURL, "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack notifier: unexpected status %v", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// PagerDutyNotifier triggers a PagerDuty Events API v2 incident.
+type PagerDutyNotifier struct {
+	RoutingKey string
+	Client     http.Client
+}
+
+// Notify implements Notifier.
+func (n *PagerDutyNotifier) Notify(a Alert) error {
+	payload := struct {
+		RoutingKey  string `json:"routing_key"`
+		EventAction string `json:"event_action"`
+		Payload     struct {
+			Summary  string `json:"summary"`
+			Source   string `json:"source"`
+			Severity string `json:"severity"`
+		} `json:"payload"`
+	}{
+		RoutingKey:  n.RoutingKey,
+		EventAction: "trigger",
+	}
+	payload.Payload.Summary = fmt.Sprintf("%s: %d events on %s", a.Rule, a.Value, a.Log)
+	payload.Payload.Source = a.Log
+	payload.Payload.Severity = "warning"
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.Client.Post("https://events.pagerduty.com/v2/enqueue", "application/json", bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pagerduty notifier: unexpected status %v", resp.StatusCode)
+	}
+
+	return nil
+}
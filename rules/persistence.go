@@ -0,0 +1,49 @@
+package rules
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// lastFiredState is the on-disk shape of the persistence file: the last time
+// each rule fired, keyed by rule name. Manager.Run uses it to restore a
+// rule's state to Firing on startup, so a restart doesn't immediately
+// re-notify for a condition that was already firing before we went down.
+type lastFiredState map[string]time.Time
+
+func loadLastFired(path string) (lastFiredState, error) {
+	state := lastFiredState{}
+
+	if path == "" {
+		return state, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	if err := json.Unmarshal(b, &state); err != nil {
+		return state, err
+	}
+
+	return state, nil
+}
+
+func saveLastFired(path string, state lastFiredState) error {
+	if path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
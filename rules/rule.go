@@ -0,0 +1,106 @@
+// Package rules implements a rules-based alerting subsystem that periodically
+// evaluates saved LEQL queries, borrowing its rule lifecycle from Prometheus's
+// rule state machine and its alert api shape from Thanos's rule api.
+package rules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Duration wraps time.Duration so Interval and For can be written as
+// Prometheus-style duration strings ("1m", "30s") in the rules YAML file,
+// since yaml.v2 has no native time.Duration support.
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %v", s, err)
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// State is the lifecycle state of a Rule.
+type State int
+
+// A Rule starts Inactive, becomes Pending as soon as its threshold is
+// exceeded, and only becomes Firing once it stays exceeded for at least For.
+const (
+	StateInactive State = iota
+	StatePending
+	StateFiring
+)
+
+func (s State) String() string {
+	switch s {
+	case StatePending:
+		return "pending"
+	case StateFiring:
+		return "firing"
+	default:
+		return "inactive"
+	}
+}
+
+// Rule is a single alerting rule loaded from the rules YAML file: it counts
+// the events returned by Query against Log every Interval, and fires once the
+// count stays above Threshold for at least For.
+type Rule struct {
+	Name        string            `yaml:"name"`
+	Log         string            `yaml:"log"`
+	Query       string            `yaml:"query"`
+	Interval    Duration          `yaml:"interval"`
+	For         Duration          `yaml:"for"`
+	Threshold   int               `yaml:"threshold"`
+	Annotations map[string]string `yaml:"annotations"`
+	Labels      map[string]string `yaml:"labels"`
+
+	state       State
+	activeSince time.Time
+	lastFired   time.Time
+	lastValue   int
+}
+
+// File is the top-level shape of the rules YAML file.
+type File struct {
+	Rules     []*Rule        `yaml:"rules"`
+	Notifiers NotifierConfig `yaml:"notifiers"`
+}
+
+// LoadRules reads and parses a rules YAML file, returning its rules and the
+// notifiers configured by its notifiers: section.
+func LoadRules(path string) ([]*Rule, []Notifier, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var f File
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, nil, err
+	}
+
+	for _, r := range f.Rules {
+		if r.Interval <= 0 {
+			return nil, nil, fmt.Errorf("rule %q: interval must be a positive duration", r.Name)
+		}
+	}
+
+	return f.Rules, f.Notifiers.Build(), nil
+}
@@ -0,0 +1,257 @@
+// Package server exposes the gle client over an HTTP REST API, mirroring the
+// shape of Prometheus's web/api/v1 handlers: every endpoint replies with an
+// {status, data, error} envelope, and long-running queries are tracked in an
+// in-memory registry so clients can poll for results instead of the cli's
+// recursive link following.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rfunix/gle/client"
+	"github.com/rfunix/gle/rules"
+)
+
+// queryTimeout bounds how long a background query is allowed to keep
+// following poll links for, independently of any client still watching it.
+const queryTimeout = 15 * time.Minute
+
+// queryResultTTL is how long a finished query's result stays in s.queries
+// before being evicted, so long-running servers don't leak an entry per
+// query ever posted.
+const queryResultTTL = 10 * time.Minute
+
+type envelope struct {
+	Status string      `json:"status"`
+	Data   interface{} `json:"data,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// query tracks an in-flight or finished POST /api/v1/query request.
+type query struct {
+	events []string
+	done   bool
+	err    error
+}
+
+// Server serves the gle REST api for a single logentries account.
+type Server struct {
+	apiKey string
+	rules  *rules.Manager
+
+	mu      sync.Mutex
+	queries map[string]*query
+	nextID  int64
+}
+
+// New creates a Server bound to the given logentries api key.
+func New(apiKey string) *Server {
+	return &Server{
+		apiKey:  apiKey,
+		queries: make(map[string]*query),
+	}
+}
+
+// SetRuleManager attaches the rules.Manager backing /api/v1/rules and
+// /api/v1/alerts. Both endpoints return an empty list until one is set.
+func (s *Server) SetRuleManager(m *rules.Manager) {
+	s.rules = m
+}
+
+// Handler returns the http.Handler serving every gle api route.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/logs", s.handleLogs)
+	mux.HandleFunc("/api/v1/query", s.handleQuery)
+	mux.HandleFunc("/api/v1/query/", s.handleQueryStatus)
+	mux.HandleFunc("/api/v1/rules", s.handleRules)
+	mux.HandleFunc("/api/v1/alerts", s.handleAlerts)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, e envelope) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, envelope{Status: "error", Error: err.Error()})
+}
+
+// GET /api/v1/logs
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	cmd := client.NewCommand("", s.apiKey, "", "", "")
+
+	lgs, err := cmd.FetchLogs(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Status: "success", Data: lgs})
+}
+
+type queryRequest struct {
+	Log   string `json:"log"`
+	Query string `json:"query"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// POST /api/v1/query {log, query, start, end}
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	var qr queryRequest
+	if err := json.NewDecoder(r.Body).Decode(&qr); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cmd := client.NewCommand(qr.Log, s.apiKey, qr.Start, qr.End, qr.Query)
+
+	lgs, err := cmd.FetchLogs(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	l, err := lgs.GetLogByName(qr.Log)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	pqr, err := cmd.PostQuery(r.Context(), l.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	id := s.register()
+	go s.run(id, cmd, pqr)
+
+	writeJSON(w, http.StatusAccepted, envelope{Status: "success", Data: map[string]string{"id": id}})
+}
+
+func (s *Server) register() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := fmt.Sprintf("%d", atomic.AddInt64(&s.nextID, 1))
+	s.queries[id] = &query{}
+	return id
+}
+
+// run keeps polling after the request that started it has been answered, so
+// it deliberately uses its own context bounded by queryTimeout rather than
+// the originating request's context. Once done, it schedules the query's
+// eviction from s.queries after queryResultTTL.
+func (s *Server) run(id string, cmd *client.Command, pqr *client.PostQueryResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var events []string
+	var err error
+
+	if len(pqr.Links) > 0 {
+		events, err = cmd.HandleLogs(ctx, pqr.Links[0].Href)
+	}
+
+	s.mu.Lock()
+	if q, ok := s.queries[id]; ok {
+		q.done = true
+		q.events = events
+		q.err = err
+	}
+	s.mu.Unlock()
+
+	time.AfterFunc(queryResultTTL, func() {
+		s.mu.Lock()
+		delete(s.queries, id)
+		s.mu.Unlock()
+	})
+}
+
+// GET /api/v1/query/{id}
+func (s *Server) handleQueryStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/query/")
+
+	s.mu.Lock()
+	q, ok := s.queries[id]
+	var done bool
+	var events []string
+	var qErr error
+	if ok {
+		done, events, qErr = q.done, q.events, q.err
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("query %v not found", id))
+		return
+	}
+
+	if !done {
+		writeJSON(w, http.StatusAccepted, envelope{Status: "success", Data: map[string]interface{}{"id": id, "done": false}})
+		return
+	}
+
+	if qErr != nil {
+		writeError(w, http.StatusInternalServerError, qErr)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Status: "success", Data: map[string]interface{}{"id": id, "done": true, "events": events}})
+}
+
+// GET /api/v1/rules
+func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	statuses := []rules.RuleStatus{}
+	if s.rules != nil {
+		statuses = append(statuses, s.rules.Rules()...)
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Status: "success", Data: statuses})
+}
+
+// GET /api/v1/alerts
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	alerts := []rules.Alert{}
+	if s.rules != nil {
+		alerts = append(alerts, s.rules.Alerts()...)
+	}
+
+	writeJSON(w, http.StatusOK, envelope{Status: "success", Data: alerts})
+}
@@ -0,0 +1,55 @@
+package stats
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics exposes gle's query statistics as Prometheus collectors, so gle
+// invocations run from cron/CI can be scraped and alerted on through
+// --metrics-addr.
+type Metrics struct {
+	QueryDuration    prometheus.Histogram
+	EventsReturned   prometheus.Counter
+	APIRequestsTotal *prometheus.CounterVec
+}
+
+// NewMetrics creates and registers gle's collectors.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		QueryDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "gle_query_duration_seconds",
+			Help: "Wall-clock time spent running a single gle query, including polling.",
+		}),
+		EventsReturned: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "gle_events_returned_total",
+			Help: "Total number of log events returned across every query.",
+		}),
+		APIRequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gle_api_requests_total",
+			Help: "Total number of requests made to the logentries api, by response status.",
+		}, []string{"status"}),
+	}
+
+	prometheus.MustRegister(m.QueryDuration, m.EventsReturned, m.APIRequestsTotal)
+
+	return m
+}
+
+// Record folds a finished Query into the registered collectors.
+func (m *Metrics) Record(q Query) {
+	m.QueryDuration.Observe(q.Duration.Seconds())
+	m.EventsReturned.Add(float64(q.Events))
+
+	for _, rt := range q.RoundTrips {
+		m.APIRequestsTotal.WithLabelValues(strconv.Itoa(rt.Status)).Inc()
+	}
+}
+
+// Handler serves the registered collectors for a Prometheus scraper.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Reporter prints a Query's stats according to the cli's --stats level:
+// "summary" prints one line to stderr each time Report is called, "all"
+// also appends the per-poll breakdown as NDJSON to File. Nothing orders
+// these lines relative to the events printed on stdout.
+type Reporter struct {
+	Level string
+	File  string
+
+	mu sync.Mutex
+}
+
+// Report writes q according to r.Level. The none level is a no-op.
+func (r *Reporter) Report(q Query) error {
+	switch r.Level {
+	case "summary":
+		r.printSummary(q)
+	case "all":
+		r.printSummary(q)
+		return r.appendNDJSON(q)
+	}
+
+	return nil
+}
+
+func (r *Reporter) printSummary(q Query) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	polls := 0
+	for _, rt := range q.RoundTrips {
+		if rt.Method == "GET" {
+			polls++
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "gle: stats log=%s events=%d bytes=%d duration=%s polls=%d\n",
+		q.Log, q.Events, q.Bytes, q.Duration, polls)
+}
+
+func (r *Reporter) appendNDJSON(q Query) error {
+	if r.File == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, err := os.OpenFile(r.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return writePollBreakdown(f, q)
+}
+
+func writePollBreakdown(w io.Writer, q Query) error {
+	for _, rt := range q.RoundTrips {
+		b, err := json.Marshal(struct {
+			Log string `json:"log"`
+			RoundTrip
+		}{Log: q.Log, RoundTrip: rt})
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package stats records per-query execution metrics — wall-clock time, HTTP
+// round-trip latency, events returned and bytes read — mirroring the shape
+// of Prometheus's query stats reporting, and optionally exposes them as
+// Prometheus counters so cron/CI invocations of gle can be scraped.
+package stats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rfunix/gle/client"
+)
+
+// RoundTrip is one HTTP round trip recorded while running a query.
+type RoundTrip struct {
+	Method   string        `json:"method"`
+	Status   int           `json:"status"`
+	Duration time.Duration `json:"duration_ns"`
+	Bytes    int64         `json:"bytes"`
+}
+
+// Query aggregates every RoundTrip made while running a single query.
+type Query struct {
+	Log        string        `json:"log"`
+	Started    time.Time     `json:"started"`
+	Duration   time.Duration `json:"duration_ns"`
+	Events     int           `json:"events"`
+	Bytes      int64         `json:"bytes"`
+	RoundTrips []RoundTrip   `json:"round_trips,omitempty"`
+}
+
+// Recorder accumulates the RoundTrips made by a single client.Command
+// execution. Its Observe method matches the signature client.Command.OnRequest
+// expects, so wiring it up is just `cmd.OnRequest = recorder.Observe`.
+type Recorder struct {
+	log     string
+	started time.Time
+
+	mu         sync.Mutex
+	roundTrips []RoundTrip
+}
+
+// NewRecorder creates a Recorder for a query against the given log.
+func NewRecorder(log string) *Recorder {
+	return &Recorder{log: log, started: time.Now()}
+}
+
+// Observe records a single HTTP round trip.
+func (r *Recorder) Observe(s client.RequestStat) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.roundTrips = append(r.roundTrips, RoundTrip{
+		Method:   s.Method,
+		Status:   s.Status,
+		Duration: s.Duration,
+		Bytes:    s.Bytes,
+	})
+}
+
+// Finish produces the Query summary once every event has been collected.
+func (r *Recorder) Finish(events int) Query {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var bytes int64
+	for _, rt := range r.roundTrips {
+		bytes += rt.Bytes
+	}
+
+	return Query{
+		Log:        r.log,
+		Started:    r.started,
+		Duration:   time.Since(r.started),
+		Events:     events,
+		Bytes:      bytes,
+		RoundTrips: append([]RoundTrip(nil), r.roundTrips...),
+	}
+}